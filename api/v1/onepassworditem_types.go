@@ -28,22 +28,88 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// OnePasswordItemType identifies the shape of the 1Password item a
+// OnePasswordItem resource is pointing at.
+// +kubebuilder:validation:Enum=login;password;secure-note
+type OnePasswordItemType string
+
+const (
+	// OnePasswordItemTypeLogin targets a 1Password Login item.
+	OnePasswordItemTypeLogin OnePasswordItemType = "login"
+	// OnePasswordItemTypePassword targets a 1Password Password item.
+	OnePasswordItemTypePassword OnePasswordItemType = "password"
+	// OnePasswordItemTypeSecureNote targets a 1Password Secure Note item.
+	OnePasswordItemTypeSecureNote OnePasswordItemType = "secure-note"
+)
+
+// FieldSelector picks a single field off of a 1Password item and maps it to
+// a key in the generated Kubernetes Secret.
+type FieldSelector struct {
+	// FieldName is the name of the field on the 1Password item, e.g. "password".
+	FieldName string `json:"fieldName"`
+
+	// SecretKey is the key the field's value is stored under in the target
+	// Secret's data map. Defaults to FieldName when omitted.
+	// +optional
+	SecretKey string `json:"secretKey,omitempty"`
+}
+
+// TargetSecret describes the Kubernetes Secret that should be created or
+// updated with the contents of the referenced 1Password item.
+type TargetSecret struct {
+	// Name is the name of the Secret to create or update.
+	Name string `json:"name"`
+
+	// Labels are applied to the generated Secret in addition to the ones the
+	// operator manages itself.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are applied to the generated Secret in addition to the ones
+	// the operator manages itself.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Type sets the Kubernetes Secret type, e.g. "Opaque" or
+	// "kubernetes.io/dockerconfigjson". Defaults to "Opaque".
+	// +optional
+	Type string `json:"type,omitempty"`
+}
 
 // OnePasswordItemSpec defines the desired state of OnePasswordItem
 type OnePasswordItemSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
-
-	// Foo is an example field of OnePasswordItem. Edit onepassworditem_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	// ItemPath is the path to the 1Password item, e.g.
+	// "vaults/<vaultRef>/items/<itemRef>".
+	ItemPath string `json:"itemPath"`
+
+	// Type is the kind of 1Password item ItemPath refers to. Defaults to
+	// "login".
+	// +optional
+	Type OnePasswordItemType `json:"type,omitempty"`
+
+	// Fields selects individual fields from the 1Password item to copy into
+	// the target Secret. When empty, the operator copies every field it
+	// knows how to map for Type.
+	// +optional
+	Fields []FieldSelector `json:"fields,omitempty"`
+
+	// SecretName is the target Secret the item's contents are synced into.
+	SecretName TargetSecret `json:"secretName"`
 }
 
 // OnePasswordItemStatus defines the observed state of OnePasswordItem
 type OnePasswordItemStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Conditions represent the latest available observations of the
+	// OnePasswordItem's state, e.g. "Ready" or "VaultAccessDenied". Consumers
+	// can `kubectl wait --for=condition=Ready` on this.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ObservedItemVersion is the 1Password item version the operator last
+	// synced into SecretName. It is bumped every time the upstream item
+	// changes, independent of this resource's own generation.
+	// +optional
+	ObservedItemVersion int64 `json:"observedItemVersion,omitempty"`
 }
 
 //+kubebuilder:object:root=true
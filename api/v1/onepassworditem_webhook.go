@@ -0,0 +1,192 @@
+/*
+MIT License
+
+Copyright (c) 2020-2022 1Password
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/yaml"
+)
+
+// itemPathPattern matches "vaults/<vaultRef>/items/<itemRef>", where
+// <vaultRef> and <itemRef> are either 1Password UUIDs or titles and may not
+// contain a "/".
+var itemPathPattern = regexp.MustCompile(`^vaults/[^/]+/items/[^/]+$`)
+
+// AllowedVaultNamespaces restricts which namespaces are allowed to reference
+// a given vault, mirroring the peer-namespace allowlist used by Kilo's
+// webhook. An empty map means every namespace may reference every vault. It
+// is populated by LoadAllowedVaultNamespaces, which the operator's
+// entrypoint calls once at startup before SetupWebhookWithManager starts
+// serving, and is read-only afterwards, so it is safe to share across
+// reconciles.
+var AllowedVaultNamespaces = map[string][]string{}
+
+// LoadAllowedVaultNamespaces reads a vault-ref -> allowed-namespaces config
+// file and replaces AllowedVaultNamespaces with its contents. Call it once
+// at operator startup, before the manager starts serving webhook requests.
+func LoadAllowedVaultNamespaces(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading vault namespace allowlist %s: %w", path, err)
+	}
+
+	var cfg map[string][]string
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("decoding vault namespace allowlist %s: %w", path, err)
+	}
+	AllowedVaultNamespaces = cfg
+	return nil
+}
+
+// validatingClient is used to look up conflicting Secrets during validation.
+// It is set by SetupWebhookWithManager and is nil in tests that don't
+// exercise Secret-conflict detection.
+var validatingClient client.Reader
+
+// SetupWebhookWithManager registers the validating webhook with mgr. mgr
+// must already be constructed with webhook.Options{CertDir: certDir}
+// pointing at the same TLS material (tls.crt/tls.key) the mutating webhook
+// server in secret-injector/pkg/webhook is serving from
+// (WebhookServerParameters.CertFile/KeyFile's directory), so both webhooks
+// present the cert the API server was configured to trust for this
+// operator instead of each minting or loading their own. CertDir can only
+// be set at manager construction time, not after, so this function cannot
+// do that wiring itself.
+func (r *OnePasswordItem) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	validatingClient = mgr.GetClient()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-onepassword-com-v1-onepassworditem,mutating=false,failurePolicy=fail,sideEffects=None,groups=onepassword.com,resources=onepassworditems,verbs=create;update,versions=v1,name=vonepassworditem.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &OnePasswordItem{}
+
+// ValidateCreate implements webhook.Validator so a validating webhook will be
+// registered for the type.
+func (r *OnePasswordItem) ValidateCreate() error {
+	return r.validate(context.Background())
+}
+
+// ValidateUpdate implements webhook.Validator so a validating webhook will be
+// registered for the type.
+func (r *OnePasswordItem) ValidateUpdate(_ runtime.Object) error {
+	return r.validate(context.Background())
+}
+
+// ValidateDelete implements webhook.Validator so a validating webhook will be
+// registered for the type. Deletes are always allowed.
+func (r *OnePasswordItem) ValidateDelete() error {
+	return nil
+}
+
+func (r *OnePasswordItem) validate(ctx context.Context) error {
+	if err := r.validateItemPath(); err != nil {
+		return err
+	}
+	if err := r.validateVaultAllowed(); err != nil {
+		return err
+	}
+	if err := r.validateSecretNotOwnedByOther(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *OnePasswordItem) validateItemPath() error {
+	if !itemPathPattern.MatchString(r.Spec.ItemPath) {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: "onepassword.com", Kind: "OnePasswordItem"},
+			r.Name,
+			field.ErrorList{field.Invalid(field.NewPath("spec").Child("itemPath"), r.Spec.ItemPath,
+				`must match "vaults/<vaultRef>/items/<itemRef>"`)},
+		)
+	}
+	return nil
+}
+
+// vaultRef returns the <vaultRef> segment of Spec.ItemPath. Callers must
+// call validateItemPath first so the path is known to have this shape.
+func (r *OnePasswordItem) vaultRef() string {
+	parts := strings.Split(r.Spec.ItemPath, "/")
+	return parts[1]
+}
+
+func (r *OnePasswordItem) validateVaultAllowed() error {
+	allowed, ok := AllowedVaultNamespaces[r.vaultRef()]
+	if !ok {
+		// No entry means the vault has no allowlist configured, so every
+		// namespace may reference it.
+		return nil
+	}
+	for _, ns := range allowed {
+		if ns == r.Namespace {
+			return nil
+		}
+	}
+	return apierrors.NewForbidden(
+		schema.GroupResource{Group: "onepassword.com", Resource: "onepassworditems"},
+		r.Name,
+		fmt.Errorf("namespace %q is not allowed to reference vault %q", r.Namespace, r.vaultRef()),
+	)
+}
+
+func (r *OnePasswordItem) validateSecretNotOwnedByOther(ctx context.Context) error {
+	if validatingClient == nil {
+		return nil
+	}
+	existing := &corev1.Secret{}
+	err := validatingClient.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: r.Spec.SecretName.Name}, existing)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, owner := range existing.OwnerReferences {
+		if owner.Kind == "OnePasswordItem" && owner.UID != r.UID {
+			return apierrors.NewConflict(
+				schema.GroupResource{Group: "", Resource: "secrets"},
+				existing.Name,
+				fmt.Errorf("secret %s/%s is already owned by OnePasswordItem %q", r.Namespace, existing.Name, owner.Name),
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Command dryrun loads a pod spec from disk, runs it through the mutating
+// webhook's patch logic locally, and prints the resulting JSON patch and
+// post-patch pod YAML. It exists so operators can validate annotation
+// combinations (operator.1password.io/inject, operator.1password.io/version,
+// ...) against real workloads before rolling the webhook out to a cluster,
+// without standing up a TLS listener or an API server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/1Password/onepassword-operator/secret-injector/pkg/webhook"
+	"sigs.k8s.io/yaml"
+)
+
+func main() {
+	podFile := flag.String("test.pod", "", "path to a Pod spec (YAML or JSON), e.g. from `kubectl get pod <name> -o yaml`")
+	namespace := flag.String("test.namespace", "default", "namespace to synthesize the AdmissionReview for")
+	connectHost := flag.String("connect-host", "", "value of OP_CONNECT_HOST to inject")
+	connectTokenName := flag.String("connect-token-secret-name", "", "name of the Secret holding OP_CONNECT_TOKEN")
+	connectTokenKey := flag.String("connect-token-secret-key", "token", "key within the Connect token Secret")
+	injectionTemplateFile := flag.String("injection-template", "", "optional path to a YAML injection template")
+	flag.Parse()
+
+	if *podFile == "" {
+		fmt.Fprintln(os.Stderr, "-test.pod is required")
+		os.Exit(2)
+	}
+
+	pod, err := webhook.LoadPodFile(*podFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	config := webhook.Config{
+		ConnectHost:      *connectHost,
+		ConnectTokenName: *connectTokenName,
+		ConnectTokenKey:  *connectTokenKey,
+	}
+	if *injectionTemplateFile != "" {
+		tmpl, err := webhook.LoadInjectionTemplate(*injectionTemplateFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		config.InjectionTemplate = tmpl
+	}
+
+	whsvr := &webhook.WebhookServer{Config: config}
+
+	patch, mutated, err := whsvr.DryRun(pod, *namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println("# patch")
+	if len(patch) == 0 {
+		fmt.Println("[]")
+	} else {
+		fmt.Println(string(patch))
+	}
+
+	podYAML, err := yaml.Marshal(mutated)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("# pod")
+	fmt.Print(string(podYAML))
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadPodFile reads a Pod from a YAML or JSON file, e.g. one saved from
+// `kubectl get pod <name> -o yaml`.
+func LoadPodFile(path string) (*corev1.Pod, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading pod file %s: %w", path, err)
+	}
+
+	var pod corev1.Pod
+	if err := yaml.Unmarshal(data, &pod); err != nil {
+		return nil, fmt.Errorf("decoding pod file %s: %w", path, err)
+	}
+	return &pod, nil
+}
+
+// synthesizeAdmissionReview builds the same admission/v1 AdmissionReview the
+// API server would send on a real pod create, so DryRun exercises the exact
+// path whsvr.Serve does.
+func synthesizeAdmissionReview(pod *corev1.Pod, namespace string) (*admissionv1.AdmissionReview, error) {
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling pod: %w", err)
+	}
+
+	return &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("dry-run"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: namespace,
+			Name:      pod.Name,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}, nil
+}
+
+// DryRun runs pod through whsvr.mutate exactly as Serve would, without a TLS
+// listener, and returns the resulting JSON patch plus the pod it produces.
+// It lets users validate annotation combinations
+// (operator.1password.io/inject, operator.1password.io/version, ...) against
+// real workloads before rolling the mutating webhook out to a cluster.
+func (whsvr *WebhookServer) DryRun(pod *corev1.Pod, namespace string) (patch []byte, mutated *corev1.Pod, err error) {
+	ar, err := synthesizeAdmissionReview(pod, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp := whsvr.mutate(admissionRequestFromV1(ar.Request))
+	if resp.Result != nil {
+		return nil, nil, fmt.Errorf("mutate: %s", resp.Result.Message)
+	}
+	if len(resp.Patch) == 0 {
+		return nil, pod.DeepCopy(), nil
+	}
+
+	original, err := json.Marshal(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	jp, err := jsonpatch.DecodePatch(resp.Patch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding patch: %w", err)
+	}
+	patched, err := jp.Apply(original)
+	if err != nil {
+		return nil, nil, fmt.Errorf("applying patch: %w", err)
+	}
+
+	var mutatedPod corev1.Pod
+	if err := json.Unmarshal(patched, &mutatedPod); err != nil {
+		return nil, nil, fmt.Errorf("decoding patched pod: %w", err)
+	}
+	return resp.Patch, &mutatedPod, nil
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	jsonpatch "gomodules.xyz/jsonpatch/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// templateHashAnnotation records the sha256 of the rendered injection
+// template on every mutated pod, the same way Istio's sidecar injector
+// stamps its template hash, so rollouts can detect config drift between the
+// template on disk and what a running pod was actually injected with.
+const templateHashAnnotation = "operator.1password.io/inject-template-hash"
+
+// InjectionTemplate is a YAML "partial PodSpec" rendered through Go
+// text/template with the target pod as context, following the Istio
+// sidecar injector pattern. It replaces the hard-coded init container,
+// volume, volume mount, env var, and command-prefix construction so the
+// image, pull policy, resources, and security context can be tuned without
+// recompiling the webhook.
+type InjectionTemplate struct {
+	hash string
+	tmpl *template.Template
+}
+
+// LoadInjectionTemplate reads and parses the injection template at path.
+func LoadInjectionTemplate(path string) (*InjectionTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading injection template %s: %w", path, err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing injection template %s: %w", path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	return &InjectionTemplate{
+		hash: hex.EncodeToString(sum[:]),
+		tmpl: tmpl,
+	}, nil
+}
+
+// renderSpec executes the template against pod and returns the rendered
+// PodSpec overlay as JSON.
+func (it *InjectionTemplate) renderSpec(pod *corev1.Pod) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := it.tmpl.Execute(&buf, pod); err != nil {
+		return nil, fmt.Errorf("executing injection template: %w", err)
+	}
+
+	specJSON, err := yaml.YAMLToJSON(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("decoding rendered injection template: %w", err)
+	}
+	return specJSON, nil
+}
+
+// createTemplatePatch merges the rendered overlay into pod with a strategic
+// merge patch (so list fields like containers/volumes merge by name instead
+// of clobbering the original), stamps the template hash annotation onto the
+// result, and emits the difference as a JSON patch.
+func (it *InjectionTemplate) createTemplatePatch(pod *corev1.Pod) ([]byte, error) {
+	specJSON, err := it.renderSpec(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := json.Marshal(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	overlay, err := json.Marshal(map[string]json.RawMessage{"spec": specJSON})
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := strategicpatch.StrategicMergePatch(original, overlay, corev1.Pod{})
+	if err != nil {
+		return nil, fmt.Errorf("computing strategic merge patch: %w", err)
+	}
+
+	sum := sha256.Sum256(specJSON)
+	merged, err = stampTemplateHash(merged, hex.EncodeToString(sum[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := jsonpatch.CreatePatch(original, merged)
+	if err != nil {
+		return nil, fmt.Errorf("diffing rendered pod: %w", err)
+	}
+	return json.Marshal(ops)
+}
+
+// stampTemplateHash sets templateHashAnnotation on a marshaled Pod.
+func stampTemplateHash(podJSON []byte, hash string) ([]byte, error) {
+	var pod corev1.Pod
+	if err := json.Unmarshal(podJSON, &pod); err != nil {
+		return nil, err
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[templateHashAnnotation] = hash
+	return json.Marshal(pod)
+}
@@ -9,13 +9,16 @@ import (
 	"strings"
 
 	"github.com/golang/glog"
+	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/api/admission/v1beta1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 const (
@@ -64,8 +67,43 @@ const (
 	injectionStatus   = "operator.1password.io/status"
 	injectAnnotation  = "operator.1password.io/inject"
 	versionAnnotation = "operator.1password.io/version"
+
+	// sidecarRefreshAnnotation turns on the renewal sidecar mode: instead of
+	// (or alongside) wrapping the app command in `op run --`, the webhook
+	// injects a sidecar that reruns `op inject` on a loop so that op://
+	// references stay fresh for the lifetime of the pod.
+	sidecarRefreshAnnotation = "operator.1password.io/sidecar-refresh"
+
+	// sidecarTemplateConfigMapAnnotation names the ConfigMap holding the
+	// template file the sidecar passes to `op inject`. Required when
+	// sidecarRefreshAnnotation is set.
+	sidecarTemplateConfigMapAnnotation = "operator.1password.io/sidecar-template-configmap"
+
+	// sidecarMountPathAnnotation overrides where the rendered secrets are
+	// mounted in the app containers. Defaults to defaultSidecarMountPath.
+	sidecarMountPathAnnotation = "operator.1password.io/sidecar-mount-path"
+)
+
+const defaultSidecarMountPath = "/op/secrets"
+
+const (
+	secretsVolumeName  = "op-secrets"
+	templateVolumeName = "op-inject-template"
+	templateMountPath  = "/op/templates"
+	templateFileKey    = "template"
 )
 
+// secretsVolume is the tmpfs volume the renewal sidecar writes rendered
+// secrets into and app containers mount read-only.
+var secretsVolume = corev1.Volume{
+	Name: secretsVolumeName,
+	VolumeSource: corev1.VolumeSource{
+		EmptyDir: &corev1.EmptyDirVolumeSource{
+			Medium: corev1.StorageMediumMemory,
+		},
+	},
+}
+
 type WebhookServer struct {
 	Config Config
 	Server *http.Server
@@ -73,15 +111,21 @@ type WebhookServer struct {
 
 // Webhook Server parameters
 type WebhookServerParameters struct {
-	Port     int    // webhook server port
-	CertFile string // path to the x509 certificate for https
-	KeyFile  string // path to the x509 private key matching `CertFile`
+	Port                  int    // webhook server port
+	CertFile              string // path to the x509 certificate for https
+	KeyFile               string // path to the x509 private key matching `CertFile`
+	InjectionTemplateFile string // optional path to a YAML injection template; see LoadInjectionTemplate
 }
 
 type Config struct {
 	ConnectHost      string
 	ConnectTokenName string
 	ConnectTokenKey  string
+
+	// InjectionTemplate, when set, overrides the hard-coded init
+	// container/volume/env/command-prefix mutation with one rendered from a
+	// user-supplied YAML template. See LoadInjectionTemplate.
+	InjectionTemplate *InjectionTemplate
 }
 
 type patchOperation struct {
@@ -90,8 +134,81 @@ type patchOperation struct {
 	Value interface{} `json:"value,omitempty"`
 }
 
+// admissionRequest is a version-agnostic view of an AdmissionRequest, built
+// from either admission/v1 or the removed-in-1.22 admission/v1beta1, so that
+// mutate and friends don't need to know which one a caller sent.
+type admissionRequest struct {
+	UID         types.UID
+	Kind        metav1.GroupVersionKind
+	Namespace   string
+	Name        string
+	Operation   string
+	Subresource string
+	Object      runtime.RawExtension
+}
+
+// admissionResponse is the version-agnostic twin of admissionRequest.
+type admissionResponse struct {
+	Allowed bool
+	Patch   []byte
+	Result  *metav1.Status
+}
+
+func admissionRequestFromV1(req *admissionv1.AdmissionRequest) *admissionRequest {
+	return &admissionRequest{
+		UID:         req.UID,
+		Kind:        req.Kind,
+		Namespace:   req.Namespace,
+		Name:        req.Name,
+		Operation:   string(req.Operation),
+		Subresource: req.SubResource,
+		Object:      req.Object,
+	}
+}
+
+func admissionRequestFromV1beta1(req *v1beta1.AdmissionRequest) *admissionRequest {
+	return &admissionRequest{
+		UID:         req.UID,
+		Kind:        req.Kind,
+		Namespace:   req.Namespace,
+		Name:        req.Name,
+		Operation:   string(req.Operation),
+		Subresource: req.SubResource,
+		Object:      req.Object,
+	}
+}
+
+func (r *admissionResponse) toV1() *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{
+		Allowed: r.Allowed,
+		Result:  r.Result,
+	}
+	if len(r.Patch) > 0 {
+		resp.Patch = r.Patch
+		pt := admissionv1.PatchTypeJSONPatch
+		resp.PatchType = &pt
+	}
+	return resp
+}
+
+func (r *admissionResponse) toV1beta1() *v1beta1.AdmissionResponse {
+	resp := &v1beta1.AdmissionResponse{
+		Allowed: r.Allowed,
+		Result:  r.Result,
+	}
+	if len(r.Patch) > 0 {
+		resp.Patch = r.Patch
+		pt := v1beta1.PatchTypeJSONPatch
+		resp.PatchType = &pt
+	}
+	return resp
+}
+
 func init() {
 	_ = corev1.AddToScheme(runtimeScheme)
+	_ = admissionv1.AddToScheme(runtimeScheme)
+	_ = v1beta1.AddToScheme(runtimeScheme)
+	_ = admissionregistrationv1.AddToScheme(runtimeScheme)
 	_ = admissionregistrationv1beta1.AddToScheme(runtimeScheme)
 	_ = v1.AddToScheme(runtimeScheme)
 }
@@ -198,37 +315,58 @@ func updateAnnotation(target map[string]string, added map[string]string) (patch
 }
 
 // main mutation process
-func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse {
+func (whsvr *WebhookServer) mutate(req *admissionRequest) *admissionResponse {
 	ctx := context.Background()
-	req := ar.Request
+
+	// kubectl debug adds containers through the pods/ephemeralcontainers
+	// subresource, a well-known bypass path for admission-time policies
+	// (see CVE-2023-2727/2728), so it gets its own patch path. That path
+	// decodes the full Pod that Kubernetes 1.22+ sends; the older
+	// EphemeralContainers body is not decoded, and its containers are left
+	// unpatched.
+	if req.Subresource == "ephemeralcontainers" {
+		return whsvr.mutateEphemeralContainers(ctx, req)
+	}
+
 	var pod corev1.Pod
 	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
 		glog.Errorf("Could not unmarshal raw object: %v", err)
-		return &v1beta1.AdmissionResponse{
+		return &admissionResponse{
 			Result: &metav1.Status{
 				Message: err.Error(),
 			},
 		}
 	}
 
-	glog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v patchOperation=%v UserInfo=%v",
-		req.Kind, req.Namespace, req.Name, pod.Name, req.UID, req.Operation, req.UserInfo)
+	glog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v patchOperation=%v",
+		req.Kind, req.Namespace, req.Name, pod.Name, req.UID, req.Operation)
 
 	// determine whether to perform mutation
 	if !mutationRequired(ignoredNamespaces, &pod.ObjectMeta) {
 		glog.Infof("Skipping mutation for %s/%s due to policy check", pod.Namespace, pod.Name)
-		return &v1beta1.AdmissionResponse{
+		return &admissionResponse{
 			Allowed: true,
 		}
 	}
 
+	if whsvr.Config.InjectionTemplate != nil {
+		if _, ok := pod.Annotations[sidecarRefreshAnnotation]; ok {
+			return &admissionResponse{
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("%s and an injection template cannot both be configured for %s/%s: the template mode does not apply the renewal sidecar, choose one injection mode", sidecarRefreshAnnotation, pod.Namespace, pod.Name),
+				},
+			}
+		}
+		return whsvr.mutateWithTemplate(&pod)
+	}
+
 	containersStr := pod.Annotations[injectAnnotation]
 
 	containers := map[string]struct{}{}
 
 	if containersStr == "" {
 		glog.Infof("No mutations made for %s/%s", pod.Namespace, pod.Name)
-		return &v1beta1.AdmissionResponse{
+		return &admissionResponse{
 			Allowed: true,
 		}
 	}
@@ -242,6 +380,7 @@ func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.Admissi
 	}
 
 	mutated := false
+	var injectedContainerIndices []int
 
 	var patch []patchOperation
 	for i, c := range pod.Spec.InitContainers {
@@ -251,7 +390,7 @@ func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.Admissi
 		}
 		c, didMutate, initContainerPatch, err := whsvr.mutateContainer(ctx, &c, i)
 		if err != nil {
-			return &v1beta1.AdmissionResponse{
+			return &admissionResponse{
 				Result: &metav1.Status{
 					Message: err.Error(),
 				},
@@ -269,11 +408,12 @@ func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.Admissi
 		if !mutate {
 			continue
 		}
+		injectedContainerIndices = append(injectedContainerIndices, i)
 
 		c, didMutate, containerPatch, err := whsvr.mutateContainer(ctx, &c, i)
 		if err != nil {
 			glog.Error("Error occured mutating container: ", err)
-			return &v1beta1.AdmissionResponse{
+			return &admissionResponse{
 				Result: &metav1.Status{
 					Message: err.Error(),
 				},
@@ -286,9 +426,51 @@ func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.Admissi
 		}
 	}
 
+	for i, c := range pod.Spec.EphemeralContainers {
+		_, mutate := containers[c.Name]
+		if !mutate {
+			continue
+		}
+
+		c, didMutate, ephemeralContainerPatch, err := whsvr.mutateEphemeralContainer(ctx, &c, i)
+		if err != nil {
+			glog.Error("Error occured mutating ephemeral container: ", err)
+			return &admissionResponse{
+				Result: &metav1.Status{
+					Message: err.Error(),
+				},
+			}
+		}
+		patch = append(patch, ephemeralContainerPatch...)
+		if didMutate {
+			mutated = true
+			pod.Spec.EphemeralContainers[i] = *c
+		}
+	}
+
+	var sidecarVolumes []corev1.Volume
+	if refresh, ok := pod.Annotations[sidecarRefreshAnnotation]; ok {
+		templateConfigMap, ok := pod.Annotations[sidecarTemplateConfigMapAnnotation]
+		if !ok {
+			return &admissionResponse{
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("%s requires %s to be set", sidecarRefreshAnnotation, sidecarTemplateConfigMapAnnotation),
+				},
+			}
+		}
+		mountPath := pod.Annotations[sidecarMountPathAnnotation]
+		if mountPath == "" {
+			mountPath = defaultSidecarMountPath
+		}
+		var sidecarPatch []patchOperation
+		sidecarVolumes, sidecarPatch = whsvr.createSidecarPatch(&pod, refresh, mountPath, templateConfigMap, version, injectedContainerIndices)
+		patch = append(patch, sidecarPatch...)
+		mutated = true
+	}
+
 	if !mutated {
 		glog.Infof("No mutations made for %s/%s", pod.Namespace, pod.Name)
-		return &v1beta1.AdmissionResponse{
+		return &admissionResponse{
 			Allowed: true,
 		}
 	}
@@ -310,9 +492,32 @@ func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.Admissi
 	}
 
 	annotations := map[string]string{injectionStatus: "injected"}
-	patchBytes, err := createOPCLIPatch(&pod, annotations, []corev1.Container{binInitContainer}, patch)
+	patchBytes, err := createOPCLIPatch(&pod, annotations, []corev1.Container{binInitContainer}, sidecarVolumes, patch)
+	if err != nil {
+		return &admissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	glog.Infof("AdmissionResponse: patch=%v\n", string(patchBytes))
+	return &admissionResponse{
+		Allowed: true,
+		Patch:   patchBytes,
+	}
+}
+
+// mutateWithTemplate renders whsvr.Config.InjectionTemplate against pod and
+// returns the resulting strategic-merge-computed JSON patch. The template
+// decides for itself, based on the pod it's handed, which containers to
+// touch and how, so none of the annotation/per-container logic below
+// applies in this mode.
+func (whsvr *WebhookServer) mutateWithTemplate(pod *corev1.Pod) *admissionResponse {
+	patchBytes, err := whsvr.Config.InjectionTemplate.createTemplatePatch(pod)
 	if err != nil {
-		return &v1beta1.AdmissionResponse{
+		glog.Errorf("Could not render injection template for %s/%s: %v", pod.Namespace, pod.Name, err)
+		return &admissionResponse{
 			Result: &metav1.Status{
 				Message: err.Error(),
 			},
@@ -320,31 +525,210 @@ func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.Admissi
 	}
 
 	glog.Infof("AdmissionResponse: patch=%v\n", string(patchBytes))
-	return &v1beta1.AdmissionResponse{
+	return &admissionResponse{
 		Allowed: true,
 		Patch:   patchBytes,
-		PatchType: func() *v1beta1.PatchType {
-			pt := v1beta1.PatchTypeJSONPatch
-			return &pt
-		}(),
 	}
 }
 
+// mutateEphemeralContainers handles AdmissionReviews for the
+// pods/ephemeralcontainers subresource. Since Kubernetes 1.22, that
+// subresource's body is a full *v1.Pod (see client-go's
+// PodInterface.UpdateEphemeralContainers, which PUTs the Pod itself), so it
+// decodes exactly like the main create/update path. The op binary volume is
+// assumed to already be present on the pod from its initial mutation, so
+// only the per-container command/volumeMount/env patches are emitted here.
+// For that reason a pod already marked injected is not skipped, and
+// ephemeral containers whose command is already wrapped with op run are
+// left as they are.
+//
+// This legacy annotation-driven injection is applied even when
+// whsvr.Config.InjectionTemplate is set, because a template renders the
+// full mutation for a pod at create time and has no equivalent entry point
+// for a later ephemeralcontainers update.
+func (whsvr *WebhookServer) mutateEphemeralContainers(ctx context.Context, req *admissionRequest) *admissionResponse {
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		glog.Errorf("Could not unmarshal raw object: %v", err)
+		return &admissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	if whsvr.Config.InjectionTemplate != nil {
+		glog.Warningf("InjectionTemplate is configured but ephemeralcontainers admission requests are not rendered through it; falling back to legacy annotation-driven injection for %s/%s", req.Namespace, pod.Name)
+	}
+
+	glog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v patchOperation=%v",
+		req.Kind, req.Namespace, req.Name, pod.Name, req.UID, req.Operation)
+
+	for _, namespace := range ignoredNamespaces {
+		if pod.Namespace == namespace {
+			return &admissionResponse{Allowed: true}
+		}
+	}
+
+	containersStr := pod.Annotations[injectAnnotation]
+	if containersStr == "" {
+		glog.Infof("No mutations made for %s/%s", pod.Namespace, pod.Name)
+		return &admissionResponse{
+			Allowed: true,
+		}
+	}
+	containers := map[string]struct{}{}
+	for _, container := range strings.Split(containersStr, ",") {
+		containers[container] = struct{}{}
+	}
+
+	mutated := false
+	var patch []patchOperation
+	for i, c := range pod.Spec.EphemeralContainers {
+		_, mutate := containers[c.Name]
+		if !mutate {
+			continue
+		}
+		if len(c.Command) > 0 && c.Command[0] == binVolumeMountPath+"op" {
+			continue
+		}
+
+		c, didMutate, ephemeralContainerPatch, err := whsvr.mutateEphemeralContainer(ctx, &c, i)
+		if err != nil {
+			glog.Error("Error occured mutating ephemeral container: ", err)
+			return &admissionResponse{
+				Result: &metav1.Status{
+					Message: err.Error(),
+				},
+			}
+		}
+		patch = append(patch, ephemeralContainerPatch...)
+		if didMutate {
+			mutated = true
+			pod.Spec.EphemeralContainers[i] = *c
+		}
+	}
+
+	if !mutated {
+		glog.Infof("No mutations made for %s/%s", pod.Namespace, pod.Name)
+		return &admissionResponse{
+			Allowed: true,
+		}
+	}
+
+	patch = append(patch, updateAnnotation(pod.Annotations, map[string]string{injectionStatus: "injected"})...)
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return &admissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	glog.Infof("AdmissionResponse: patch=%v\n", string(patchBytes))
+	return &admissionResponse{
+		Allowed: true,
+		Patch:   patchBytes,
+	}
+}
+
+// templateVolume is the read-only ConfigMap volume the renewal sidecar reads
+// its `op inject` template from.
+func templateVolume(configMapName string) corev1.Volume {
+	return corev1.Volume{
+		Name: templateVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+			},
+		},
+	}
+}
+
+// secretsVolumeMount is the read-only mount app containers use to pick up
+// secrets rendered by the renewal sidecar.
+func secretsVolumeMount(mountPath string) corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      secretsVolumeName,
+		MountPath: mountPath,
+		ReadOnly:  true,
+	}
+}
+
+// renewalSidecarContainer runs `op inject` against the templated ConfigMap
+// on a loop at the given interval, writing the rendered output into the
+// shared tmpfs secretsVolume so app containers can pick up rotated values
+// without restarting.
+func renewalSidecarContainer(version, refresh, mountPath string) corev1.Container {
+	loop := fmt.Sprintf("while true; do %sop inject -i %s/%s -o %s/env; sleep %s; done",
+		binVolumeMountPath, templateMountPath, templateFileKey, mountPath, refresh)
+
+	return corev1.Container{
+		Name:            "op-secret-renewer",
+		Image:           "1password/op" + ":" + version,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"sh", "-c", loop},
+		VolumeMounts: []corev1.VolumeMount{
+			binVolumeMount,
+			{
+				Name:      templateVolumeName,
+				MountPath: templateMountPath,
+				ReadOnly:  true,
+			},
+			{
+				Name:      secretsVolumeName,
+				MountPath: mountPath,
+			},
+		},
+	}
+}
+
+// createSidecarPatch builds the patch that injects the renewal sidecar and
+// the read-only secrets mount on every already-targeted app container. It
+// returns the volumes the sidecar needs separately rather than patching
+// pod.Spec.Volumes itself: the caller combines them with any other new
+// volumes (e.g. binVolume) into a single addVolume call, since two
+// independent addVolume calls against the same never-updated
+// pod.Spec.Volumes would each think they're first and emit a clobbering
+// "add" at the bare "/spec/volumes" path instead of appending.
+func (whsvr *WebhookServer) createSidecarPatch(pod *corev1.Pod, refresh, mountPath, templateConfigMap, version string, containerIndices []int) (volumes []corev1.Volume, patch []patchOperation) {
+	volumes = []corev1.Volume{secretsVolume, templateVolume(templateConfigMap)}
+
+	sidecarIndex := len(pod.Spec.Containers)
+	sidecar := renewalSidecarContainer(version, refresh, mountPath)
+	patch = append(patch, addContainers(pod.Spec.Containers, []corev1.Container{sidecar}, "/spec/containers")...)
+	patch = append(patch, createOPConnectPatch(sidecar.Env, sidecarIndex, whsvr.Config.ConnectHost, whsvr.Config.ConnectTokenName, whsvr.Config.ConnectTokenKey, "/spec/containers")...)
+
+	for _, i := range containerIndices {
+		path := fmt.Sprintf("%s/%d/volumeMounts/-", "/spec/containers", i)
+		patch = append(patch, patchOperation{
+			Op:    "add",
+			Path:  path,
+			Value: secretsVolumeMount(mountPath),
+		})
+	}
+
+	return volumes, patch
+}
+
 // create mutation patch for resoures
-func createOPCLIPatch(pod *corev1.Pod, annotations map[string]string, containers []corev1.Container, patch []patchOperation) ([]byte, error) {
+func createOPCLIPatch(pod *corev1.Pod, annotations map[string]string, containers []corev1.Container, extraVolumes []corev1.Volume, patch []patchOperation) ([]byte, error) {
 
-	patch = append(patch, addVolume(pod.Spec.Volumes, []corev1.Volume{binVolume}, "/spec/volumes")...)
+	volumes := append([]corev1.Volume{binVolume}, extraVolumes...)
+	patch = append(patch, addVolume(pod.Spec.Volumes, volumes, "/spec/volumes")...)
 	patch = append(patch, addContainers(pod.Spec.InitContainers, containers, "/spec/initContainers")...)
 	patch = append(patch, updateAnnotation(pod.Annotations, annotations)...)
 
 	return json.Marshal(patch)
 }
 
-func createOPConnectPatch(container *corev1.Container, containerIndex int, host, tokenSecretName, tokenSecretKey string) []patchOperation {
+func createOPConnectPatch(env []corev1.EnvVar, containerIndex int, host, tokenSecretName, tokenSecretKey, basePath string) []patchOperation {
 	var patch []patchOperation
 	envs := []corev1.EnvVar{}
 
-	hostConfig, tokenConfig := isConnectConfigurationSet(container)
+	hostConfig, tokenConfig := isConnectConfigurationSet(env)
 
 	if hostConfig {
 		connectHostEnvVar := corev1.EnvVar{
@@ -369,22 +753,22 @@ func createOPConnectPatch(container *corev1.Container, containerIndex int, host,
 		envs = append(envs, connectTokenEnvVar)
 	}
 
-	patch = append(patch, setEnvironment(*container, containerIndex, envs, "/spec/containers")...)
+	patch = append(patch, setEnvironment(env, containerIndex, envs, basePath)...)
 
 	return patch
 }
 
-func isConnectConfigurationSet(container *corev1.Container) (bool, bool) {
+func isConnectConfigurationSet(env []corev1.EnvVar) (bool, bool) {
 
 	hostConfig := false
 	tokenConfig := false
 
-	for _, env := range container.Env {
-		if env.Name == connectHostEnv {
+	for _, e := range env {
+		if e.Name == connectHostEnv {
 			hostConfig = true
 		}
 
-		if env.Name == connectTokenEnv {
+		if e.Name == connectTokenEnv {
 			tokenConfig = true
 		}
 
@@ -424,12 +808,47 @@ func (whsvr *WebhookServer) mutateContainer(_ context.Context, container *corev1
 	})
 
 	//creating patch for adding conenct environment variables to container
-	patch = append(patch, createOPConnectPatch(container, containerIndex, whsvr.Config.ConnectHost, whsvr.Config.ConnectTokenName, whsvr.Config.ConnectTokenKey)...)
+	patch = append(patch, createOPConnectPatch(container.Env, containerIndex, whsvr.Config.ConnectHost, whsvr.Config.ConnectTokenName, whsvr.Config.ConnectTokenKey, "/spec/containers")...)
+	return container, true, patch, nil
+}
+
+// mutateEphemeralContainer applies the same op-run wrapping, binary volume
+// mount, and Connect env injection as mutateContainer, but for an ephemeral
+// debug container. Ephemeral containers are a documented bypass path for
+// admission-time policies (see CVE-2023-2727/2728), so kubectl debug
+// sessions need the exact same treatment as regular containers. Both the
+// inline-at-create path and the pods/ephemeralcontainers subresource path
+// decode a full Pod, so there's a single basePath for both.
+func (whsvr *WebhookServer) mutateEphemeralContainer(_ context.Context, container *corev1.EphemeralContainer, containerIndex int) (*corev1.EphemeralContainer, bool, []patchOperation, error) {
+	if len(container.Command) == 0 {
+		return container, false, nil, fmt.Errorf("not attaching OP to the ephemeral container %s: the podspec does not define a command", container.Name)
+	}
+
+	container.Command = append([]string{binVolumeMountPath + "op", "run", "--"}, container.Command...)
+
+	const basePath = "/spec/ephemeralContainers"
+	var patch []patchOperation
+
+	path := fmt.Sprintf("%s/%d/volumeMounts", basePath, containerIndex)
+	patch = append(patch, patchOperation{
+		Op:    "add",
+		Path:  path,
+		Value: []corev1.VolumeMount{binVolumeMount},
+	})
+
+	path = fmt.Sprintf("%s/%d/command", basePath, containerIndex)
+	patch = append(patch, patchOperation{
+		Op:    "replace",
+		Path:  path,
+		Value: container.Command,
+	})
+
+	patch = append(patch, createOPConnectPatch(container.Env, containerIndex, whsvr.Config.ConnectHost, whsvr.Config.ConnectTokenName, whsvr.Config.ConnectTokenKey, basePath)...)
 	return container, true, patch, nil
 }
 
-func setEnvironment(container corev1.Container, containerIndex int, addedEnv []corev1.EnvVar, basePath string) (patch []patchOperation) {
-	first := len(container.Env) == 0
+func setEnvironment(env []corev1.EnvVar, containerIndex int, addedEnv []corev1.EnvVar, basePath string) (patch []patchOperation) {
+	first := len(env) == 0
 	var value interface{}
 	for _, add := range addedEnv {
 		path := fmt.Sprintf("%s/%d/env", basePath, containerIndex)
@@ -471,35 +890,72 @@ func (whsvr *WebhookServer) Serve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var admissionResponse *v1beta1.AdmissionResponse
-	ar := v1beta1.AdmissionReview{}
-	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+	// Kubernetes 1.22 removed admission/v1beta1, but older clusters only
+	// speak it, so peek at the AdmissionReview's own apiVersion and decode
+	// down whichever branch applies. mutate and friends only ever see the
+	// version-agnostic admissionRequest/admissionResponse pair.
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(body, &typeMeta); err != nil {
 		glog.Errorf("Can't decode body: %v", err)
-		admissionResponse = &v1beta1.AdmissionResponse{
-			Result: &metav1.Status{
-				Message: err.Error(),
-			},
-		}
-	} else {
-		admissionResponse = whsvr.mutate(&ar)
+		http.Error(w, fmt.Sprintf("could not decode body: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	admissionReview := v1beta1.AdmissionReview{}
-	if admissionResponse != nil {
-		admissionReview.Response = admissionResponse
-		if ar.Request != nil {
-			admissionReview.Response.UID = ar.Request.UID
-		}
+	var respBytes []byte
+	var err error
+	switch typeMeta.APIVersion {
+	case admissionv1.SchemeGroupVersion.String():
+		respBytes, err = whsvr.serveV1(body)
+	case v1beta1.SchemeGroupVersion.String():
+		respBytes, err = whsvr.serveV1beta1(body)
+	default:
+		err = fmt.Errorf("unsupported AdmissionReview apiVersion %q", typeMeta.APIVersion)
 	}
-
-	resp, err := json.Marshal(admissionReview)
 	if err != nil {
 		glog.Errorf("Can't encode response: %v", err)
 		http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
+		return
 	}
+
 	glog.Infof("Ready to write reponse ...")
-	if _, err := w.Write(resp); err != nil {
+	if _, err := w.Write(respBytes); err != nil {
 		glog.Errorf("Can't write response: %v", err)
 		http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
 	}
 }
+
+func (whsvr *WebhookServer) serveV1(body []byte) ([]byte, error) {
+	ar := admissionv1.AdmissionReview{}
+	var resp *admissionResponse
+	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+		glog.Errorf("Can't decode body: %v", err)
+		resp = &admissionResponse{Result: &metav1.Status{Message: err.Error()}}
+	} else {
+		resp = whsvr.mutate(admissionRequestFromV1(ar.Request))
+	}
+
+	review := admissionv1.AdmissionReview{TypeMeta: ar.TypeMeta}
+	review.Response = resp.toV1()
+	if ar.Request != nil {
+		review.Response.UID = ar.Request.UID
+	}
+	return json.Marshal(review)
+}
+
+func (whsvr *WebhookServer) serveV1beta1(body []byte) ([]byte, error) {
+	ar := v1beta1.AdmissionReview{}
+	var resp *admissionResponse
+	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+		glog.Errorf("Can't decode body: %v", err)
+		resp = &admissionResponse{Result: &metav1.Status{Message: err.Error()}}
+	} else {
+		resp = whsvr.mutate(admissionRequestFromV1beta1(ar.Request))
+	}
+
+	review := v1beta1.AdmissionReview{TypeMeta: ar.TypeMeta}
+	review.Response = resp.toV1beta1()
+	if ar.Request != nil {
+		review.Response.UID = ar.Request.UID
+	}
+	return json.Marshal(review)
+}
@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// applyPatch mirrors what DryRun does: marshal original, decode the
+// patchOperation JSON as an RFC 6902 patch, and apply it, so tests exercise
+// exactly the bytes the API server would receive.
+func applyPatch(t *testing.T, original interface{}, patch []byte) []byte {
+	t.Helper()
+	origJSON, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("marshaling original: %v", err)
+	}
+	jp, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		t.Fatalf("decoding patch: %v", err)
+	}
+	out, err := jp.Apply(origJSON)
+	if err != nil {
+		t.Fatalf("applying patch: %v", err)
+	}
+	return out
+}
+
+// TestMutate_SidecarRefresh_NoExistingVolumes is a regression test for the
+// addVolume clobbering bug: with zero volumes on the pod, the sidecar's
+// secrets/template volumes and the op binary volume used to race to "be
+// first", and the one applied last won, dropping the other.
+func TestMutate_SidecarRefresh_NoExistingVolumes(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Annotations: map[string]string{
+				injectAnnotation:                   "app",
+				sidecarRefreshAnnotation:           "5m",
+				sidecarTemplateConfigMapAnnotation: "op-inject-template",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Command: []string{"./app"}},
+			},
+		},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("marshaling pod: %v", err)
+	}
+
+	whsvr := &WebhookServer{}
+	resp := whsvr.mutate(&admissionRequest{Object: runtime.RawExtension{Raw: raw}})
+	if resp.Result != nil {
+		t.Fatalf("mutate returned an error: %s", resp.Result.Message)
+	}
+
+	mutatedJSON := applyPatch(t, pod, resp.Patch)
+	var mutated corev1.Pod
+	if err := json.Unmarshal(mutatedJSON, &mutated); err != nil {
+		t.Fatalf("decoding mutated pod: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, v := range mutated.Spec.Volumes {
+		names[v.Name] = true
+	}
+	for _, want := range []string{binVolumeName, secretsVolumeName, templateVolumeName} {
+		if !names[want] {
+			t.Errorf("mutated pod is missing volume %q, got volumes %v", want, mutated.Spec.Volumes)
+		}
+	}
+	if len(mutated.Spec.Volumes) != 3 {
+		t.Errorf("expected exactly 3 volumes, got %d: %v", len(mutated.Spec.Volumes), mutated.Spec.Volumes)
+	}
+}
+
+// TestMutateEphemeralContainers_SubresourcePatchPaths is a regression test
+// for the pods/ephemeralcontainers subresource decoding: since Kubernetes
+// 1.22, that subresource's body is a full *v1.Pod, so patches must target
+// "/spec/ephemeralContainers" exactly like the inline-at-create path.
+func TestMutateEphemeralContainers_SubresourcePatchPaths(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Annotations: map[string]string{
+				injectAnnotation: "debugger",
+			},
+		},
+		Spec: corev1.PodSpec{
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger", Command: []string{"sh"}}},
+			},
+		},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("marshaling pod: %v", err)
+	}
+
+	whsvr := &WebhookServer{}
+	resp := whsvr.mutate(&admissionRequest{Subresource: "ephemeralcontainers", Object: runtime.RawExtension{Raw: raw}})
+	if resp.Result != nil {
+		t.Fatalf("mutate returned an error: %s", resp.Result.Message)
+	}
+
+	var ops []patchOperation
+	if err := json.Unmarshal(resp.Patch, &ops); err != nil {
+		t.Fatalf("decoding patch: %v", err)
+	}
+	if len(ops) == 0 {
+		t.Fatal("expected at least one patch operation")
+	}
+	for _, op := range ops {
+		if op.Path == "/metadata/annotations" || strings.HasPrefix(op.Path, "/metadata/annotations/") {
+			continue
+		}
+		if !strings.HasPrefix(op.Path, "/spec/ephemeralContainers") {
+			t.Errorf("patch op %+v does not target /spec/ephemeralContainers", op)
+		}
+	}
+
+	mutatedJSON := applyPatch(t, pod, resp.Patch)
+	var mutated corev1.Pod
+	if err := json.Unmarshal(mutatedJSON, &mutated); err != nil {
+		t.Fatalf("decoding mutated pod: %v", err)
+	}
+	if len(mutated.Spec.EphemeralContainers[0].Command) == 0 || mutated.Spec.EphemeralContainers[0].Command[0] != binVolumeMountPath+"op" {
+		t.Errorf("expected command to be wrapped with op run, got %v", mutated.Spec.EphemeralContainers[0].Command)
+	}
+}
+
+// TestMutate_TemplateAndSidecarRefresh_Rejected is a regression test for the
+// silent feature interaction: configuring both an injection template and
+// the renewal sidecar on the same pod must be rejected, not silently
+// resolved in favor of one or the other.
+func TestMutate_TemplateAndSidecarRefresh_Rejected(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Annotations: map[string]string{
+				injectAnnotation:                   "app",
+				sidecarRefreshAnnotation:           "5m",
+				sidecarTemplateConfigMapAnnotation: "op-inject-template",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Command: []string{"./app"}}},
+		},
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("marshaling pod: %v", err)
+	}
+
+	whsvr := &WebhookServer{Config: Config{InjectionTemplate: &InjectionTemplate{}}}
+	resp := whsvr.mutate(&admissionRequest{Object: runtime.RawExtension{Raw: raw}})
+	if resp.Result == nil {
+		t.Fatal("expected mutate to reject a pod configured for both template and sidecar-refresh modes")
+	}
+}